@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/test-infra/prow/config/org"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	modeGenerate = "generate"
+	modeCheck    = "check"
+
+	reportFormatText = "text"
+	reportFormatJSON = "json"
+)
+
+// driftReport describes how peribolosConfig.Orgs[destOrg].Repos has drifted from both the live
+// GitHub org and the set of repos the tool would currently generate from official-image promotion
+// and the whitelist.
+type driftReport struct {
+	MissingOnGitHub  []string            `json:"missingOnGitHub,omitempty"`
+	ArchivedOnGitHub []string            `json:"archivedOnGitHub,omitempty"`
+	MissingFromFile  []string            `json:"missingFromFile,omitempty"`
+	FieldDiffs       map[string][]string `json:"fieldDiffs,omitempty"`
+}
+
+func (r driftReport) hasDrift() bool {
+	return len(r.MissingOnGitHub) > 0 || len(r.ArchivedOnGitHub) > 0 || len(r.MissingFromFile) > 0 || len(r.FieldDiffs) > 0
+}
+
+func (r driftReport) String() string {
+	s := ""
+	if len(r.MissingOnGitHub) > 0 {
+		s += fmt.Sprintf("repos in %s but missing on GitHub:\n", "the peribolos config")
+		for _, repo := range r.MissingOnGitHub {
+			s += fmt.Sprintf("  - %s\n", repo)
+		}
+	}
+	if len(r.ArchivedOnGitHub) > 0 {
+		s += "repos in the peribolos config that are archived on GitHub:\n"
+		for _, repo := range r.ArchivedOnGitHub {
+			s += fmt.Sprintf("  - %s\n", repo)
+		}
+	}
+	if len(r.MissingFromFile) > 0 {
+		s += "repos matching the selection criteria but absent from the peribolos config:\n"
+		for _, repo := range r.MissingFromFile {
+			s += fmt.Sprintf("  - %s\n", repo)
+		}
+	}
+	if len(r.FieldDiffs) > 0 {
+		s += "repos with field-level drift:\n"
+		names := make([]string, 0, len(r.FieldDiffs))
+		for name := range r.FieldDiffs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s += fmt.Sprintf("  - %s:\n", name)
+			for _, diff := range r.FieldDiffs[name] {
+				s += fmt.Sprintf("      %s\n", diff)
+			}
+		}
+	}
+	if s == "" {
+		return "no drift detected\n"
+	}
+	return s
+}
+
+// checkDrift compares the repos already recorded under peribolosConfig.Orgs[o.destOrg].Repos
+// against the live state of the destination GitHub org and against desiredRepos, the set the tool
+// would currently generate from official-image promotion and the whitelist. Repos GitHub reports
+// as genuinely gone (404) are reported as MissingOnGitHub; any other fetch error (a network blip, a
+// 5xx, a rate limit) is retried with backoff same as generateRepositories and, if it still doesn't
+// resolve, returned as an error rather than misreported as drift.
+func checkDrift(gc gitHubClient, o options, currentRepos, desiredRepos map[string]org.Repo, logger *logrus.Entry) (driftReport, error) {
+	report := driftReport{FieldDiffs: map[string][]string{}}
+	provider := &gitHubProvider{gc: gc}
+
+	var fetchErrs []error
+	for name, fileRepo := range currentRepos {
+		metadata, err := getRepoWithBackoff(provider, o.destOrg, name, logger)
+		if err != nil {
+			if isNotFound(err) {
+				report.MissingOnGitHub = append(report.MissingOnGitHub, name)
+				continue
+			}
+			fetchErrs = append(fetchErrs, errors.Wrap(err, name))
+			continue
+		}
+		if metadata.Archived {
+			report.ArchivedOnGitHub = append(report.ArchivedOnGitHub, name)
+		}
+
+		if diffs := diffRepoFields(fileRepo, metadata); len(diffs) > 0 {
+			report.FieldDiffs[name] = diffs
+		}
+	}
+
+	for name := range desiredRepos {
+		if _, ok := currentRepos[name]; !ok {
+			report.MissingFromFile = append(report.MissingFromFile, name)
+		}
+	}
+
+	sort.Strings(report.MissingOnGitHub)
+	sort.Strings(report.ArchivedOnGitHub)
+	sort.Strings(report.MissingFromFile)
+	if len(report.FieldDiffs) == 0 {
+		report.FieldDiffs = nil
+	}
+
+	return report, kerrors.NewAggregate(fetchErrs)
+}
+
+// isNotFound reports whether err is a 404 response from the GitHub API, i.e. the repo has actually
+// been deleted or renamed rather than merely being unreachable right now.
+func isNotFound(err error) bool {
+	var reqErr *github.RequestError
+	return errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusNotFound
+}
+
+func diffRepoFields(fileRepo org.Repo, liveRepo RepoMetadata) []string {
+	var diffs []string
+	if strPtr(fileRepo.Description) != liveRepo.Description {
+		diffs = append(diffs, fmt.Sprintf("description: file=%q live=%q", strPtr(fileRepo.Description), liveRepo.Description))
+	}
+	if strPtr(fileRepo.HomePage) != liveRepo.Homepage {
+		diffs = append(diffs, fmt.Sprintf("homepage: file=%q live=%q", strPtr(fileRepo.HomePage), liveRepo.Homepage))
+	}
+	if strPtr(fileRepo.DefaultBranch) != liveRepo.DefaultBranch {
+		diffs = append(diffs, fmt.Sprintf("defaultBranch: file=%q live=%q", strPtr(fileRepo.DefaultBranch), liveRepo.DefaultBranch))
+	}
+	if boolPtr(fileRepo.HasIssues) != liveRepo.HasIssues {
+		diffs = append(diffs, fmt.Sprintf("hasIssues: file=%t live=%t", boolPtr(fileRepo.HasIssues), liveRepo.HasIssues))
+	}
+	if boolPtr(fileRepo.HasProjects) != liveRepo.HasProjects {
+		diffs = append(diffs, fmt.Sprintf("hasProjects: file=%t live=%t", boolPtr(fileRepo.HasProjects), liveRepo.HasProjects))
+	}
+	if boolPtr(fileRepo.HasWiki) != liveRepo.HasWiki {
+		diffs = append(diffs, fmt.Sprintf("hasWiki: file=%t live=%t", boolPtr(fileRepo.HasWiki), liveRepo.HasWiki))
+	}
+	if boolPtr(fileRepo.AllowMergeCommit) != liveRepo.AllowMergeCommit {
+		diffs = append(diffs, fmt.Sprintf("allowMergeCommit: file=%t live=%t", boolPtr(fileRepo.AllowMergeCommit), liveRepo.AllowMergeCommit))
+	}
+	if boolPtr(fileRepo.AllowSquashMerge) != liveRepo.AllowSquashMerge {
+		diffs = append(diffs, fmt.Sprintf("allowSquashMerge: file=%t live=%t", boolPtr(fileRepo.AllowSquashMerge), liveRepo.AllowSquashMerge))
+	}
+	if boolPtr(fileRepo.AllowRebaseMerge) != liveRepo.AllowRebaseMerge {
+		diffs = append(diffs, fmt.Sprintf("allowRebaseMerge: file=%t live=%t", boolPtr(fileRepo.AllowRebaseMerge), liveRepo.AllowRebaseMerge))
+	}
+	return diffs
+}
+
+func strPtr(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func boolPtr(p *bool) bool {
+	if p == nil {
+		return false
+	}
+	return *p
+}
+
+func printDriftReport(report driftReport, format string) error {
+	switch format {
+	case reportFormatJSON:
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal drift report")
+		}
+		fmt.Println(string(out))
+	default:
+		fmt.Print(report.String())
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"k8s.io/test-infra/prow/config/org"
+)
+
+func strp(s string) *string { return &s }
+func boolp(b bool) *bool    { return &b }
+
+func TestDiffRepoFields(t *testing.T) {
+	fileRepo := org.Repo{
+		Description:   strp("old description"),
+		HomePage:      strp("https://old.example.com"),
+		DefaultBranch: strp("master"),
+		HasIssues:     boolp(true),
+		HasProjects:   boolp(false),
+		HasWiki:       boolp(true),
+	}
+	liveRepo := RepoMetadata{
+		Description:   "new description",
+		Homepage:      "https://old.example.com",
+		DefaultBranch: "main",
+		HasIssues:     true,
+		HasProjects:   false,
+		HasWiki:       false,
+	}
+
+	diffs := diffRepoFields(fileRepo, liveRepo)
+	sort.Strings(diffs)
+
+	want := []string{
+		`defaultBranch: file="master" live="main"`,
+		`description: file="old description" live="new description"`,
+		`hasWiki: file=true live=false`,
+	}
+	sort.Strings(want)
+
+	if len(diffs) != len(want) {
+		t.Fatalf("diffRepoFields() = %v, want %v", diffs, want)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("diffRepoFields()[%d] = %q, want %q", i, diffs[i], want[i])
+		}
+	}
+}
+
+func TestDiffRepoFieldsNoDrift(t *testing.T) {
+	fileRepo := org.Repo{
+		Description: strp("same"),
+		HasIssues:   boolp(true),
+	}
+	liveRepo := RepoMetadata{
+		Description: "same",
+		HasIssues:   true,
+	}
+
+	if diffs := diffRepoFields(fileRepo, liveRepo); len(diffs) != 0 {
+		t.Errorf("diffRepoFields() = %v, want no diffs", diffs)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config/org"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	maxGetRepoAttempts = 5
+	initialRepoBackoff = time.Second
+	maxRepoBackoff     = 2 * time.Minute
+)
+
+type repoTask struct {
+	org, repo string
+}
+
+// generateRepositories fetches metadata for every repo in orgRepos through provider, fanning the
+// calls out across `concurrency` workers. Individual repo failures are collected and returned as
+// an aggregate error rather than aborting the run, unless failFast is set, in which case no new
+// fetches are started once the first error is observed.
+func generateRepositories(provider RepoMetadataProvider, orgRepos map[string]sets.String, logger *logrus.Entry, concurrency int, failFast bool) (map[string]org.Repo, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var tasks []repoTask
+	for orgName, repos := range orgRepos {
+		for repo := range repos {
+			tasks = append(tasks, repoTask{org: orgName, repo: repo})
+		}
+	}
+
+	var (
+		mu             sync.Mutex
+		peribolosRepos = make(map[string]org.Repo)
+		errs           []error
+		abort          bool
+	)
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		mu.Lock()
+		stop := abort
+		mu.Unlock()
+		if stop {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(t repoTask) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			mu.Lock()
+			stop := abort
+			mu.Unlock()
+			if stop {
+				return
+			}
+
+			logger.WithFields(logrus.Fields{"org": t.org, "repo": t.repo}).Info("Processing repository details...")
+			metadata, err := getRepoWithBackoff(provider, t.org, t.repo, logger)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "%s/%s", t.org, t.repo))
+				if failFast {
+					abort = true
+				}
+				return
+			}
+			peribolosRepos[t.repo] = buildOrgRepo(metadata)
+		}(t)
+	}
+	wg.Wait()
+
+	return peribolosRepos, kerrors.NewAggregate(errs)
+}
+
+// getRepoWithBackoff retries a single GetRepo call on failure, honoring any rate-limit-provided
+// retry delay and otherwise backing off exponentially with jitter. A 404 is never retried: the
+// repo is simply gone, and no amount of backoff will change that.
+func getRepoWithBackoff(provider RepoMetadataProvider, orgName, repo string, logger *logrus.Entry) (RepoMetadata, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxGetRepoAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := retryBackoff(attempt, lastErr)
+			logger.WithFields(logrus.Fields{"org": orgName, "repo": repo, "attempt": attempt}).WithError(lastErr).Infof("retrying after %s", backoff)
+			time.Sleep(backoff)
+		}
+
+		metadata, err := provider.GetRepo(orgName, repo)
+		if err == nil {
+			return metadata, nil
+		}
+		if isNotFound(err) {
+			return RepoMetadata{}, err
+		}
+		lastErr = err
+	}
+	return RepoMetadata{}, lastErr
+}
+
+// retryBackoff determines how long to wait before the next attempt. It honors an explicit
+// retry-after hint from GitHub's secondary rate limiting, the reset time of GitHub's primary rate
+// limit (see wrapGitHubRateLimit) or a provider-reported HTTP 429, falling back to exponential
+// backoff with jitter otherwise.
+func retryBackoff(attempt int, err error) time.Duration {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) && abuseErr.RetryAfter > 0 {
+		return abuseErr.RetryAfter
+	}
+	var rlErr rateLimitedError
+	if errors.As(err, &rlErr) && rlErr.RetryAfter() > 0 {
+		return rlErr.RetryAfter()
+	}
+
+	backoff := initialRepoBackoff * time.Duration(int64(1)<<uint(attempt-1))
+	if backoff > maxRepoBackoff {
+		backoff = maxRepoBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	return backoff/2 + jitter
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestRetryBackoffHonorsAbuseRateLimit(t *testing.T) {
+	err := &github.AbuseRateLimitError{RetryAfter: 42 * time.Second}
+	if got := retryBackoff(1, err); got != 42*time.Second {
+		t.Errorf("retryBackoff() = %s, want %s", got, 42*time.Second)
+	}
+}
+
+func TestRetryBackoffHonorsProviderRateLimit(t *testing.T) {
+	err := &httpRateLimitError{err: errors.New("rate limited"), after: 7 * time.Second}
+	if got := retryBackoff(1, err); got != 7*time.Second {
+		t.Errorf("retryBackoff() = %s, want %s", got, 7*time.Second)
+	}
+}
+
+func TestRetryBackoffFallsBackToExponentialWithJitter(t *testing.T) {
+	testCases := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 500 * time.Millisecond, max: time.Second},
+		{attempt: 3, min: 2 * time.Second, max: 4 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		got := retryBackoff(tc.attempt, nil)
+		if got < tc.min || got > tc.max {
+			t.Errorf("retryBackoff(%d, nil) = %s, want between %s and %s", tc.attempt, got, tc.min, tc.max)
+		}
+	}
+}
+
+// fakeProvider returns errs[call] (if present and non-nil) for each successive GetRepo call,
+// falling through to a successful RepoMetadata once errs is exhausted.
+type fakeProvider struct {
+	calls int
+	errs  []error
+}
+
+func (f *fakeProvider) GetRepo(_, _ string) (RepoMetadata, error) {
+	if f.calls < len(f.errs) && f.errs[f.calls] != nil {
+		err := f.errs[f.calls]
+		f.calls++
+		return RepoMetadata{}, err
+	}
+	f.calls++
+	return RepoMetadata{Description: "ok"}, nil
+}
+
+func TestGetRepoWithBackoffDoesNotRetryNotFound(t *testing.T) {
+	provider := &fakeProvider{errs: []error{&github.RequestError{StatusCode: http.StatusNotFound}}}
+	logger := logrus.NewEntry(logrus.New())
+
+	if _, err := getRepoWithBackoff(provider, "org", "repo", logger); err == nil {
+		t.Fatal("getRepoWithBackoff() returned no error for a 404")
+	}
+	if provider.calls != 1 {
+		t.Errorf("GetRepo called %d times, want 1 (a 404 should not be retried)", provider.calls)
+	}
+}
+
+func TestGetRepoWithBackoffRetriesTransientErrors(t *testing.T) {
+	provider := &fakeProvider{errs: []error{errors.New("temporary failure")}}
+	logger := logrus.NewEntry(logrus.New())
+
+	metadata, err := getRepoWithBackoff(provider, "org", "repo", logger)
+	if err != nil {
+		t.Fatalf("getRepoWithBackoff() returned error: %v", err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("GetRepo called %d times, want 2 (one failure, one retry that succeeds)", provider.calls)
+	}
+	if metadata.Description != "ok" {
+		t.Errorf("metadata.Description = %q, want %q", metadata.Description, "ok")
+	}
+}
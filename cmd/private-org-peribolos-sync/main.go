@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +8,8 @@ import (
 	"path/filepath"
 
 	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/sirupsen/logrus"
 
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -17,6 +18,7 @@ import (
 	"k8s.io/test-infra/prow/config/org"
 	"k8s.io/test-infra/prow/config/secret"
 	"k8s.io/test-infra/prow/flagutil"
+	gitv2 "k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/interrupts"
 
@@ -29,6 +31,14 @@ type gitHubClient interface {
 	GetRepo(owner, name string) (github.FullRepo, error)
 }
 
+// prGitHubClient is the subset of github.Client needed to fork a repository and open a pull
+// request against it, used by --create-pr.
+type prGitHubClient interface {
+	gitHubClient
+	EnsureFork(owner, repo, forkName string) (string, error)
+	CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (int, error)
+}
+
 type options struct {
 	config.WhitelistOptions
 
@@ -36,6 +46,36 @@ type options struct {
 	destOrg         string
 	releaseRepoPath string
 	github          flagutil.GitHubOptions
+
+	dryRun bool
+	debug  bool
+
+	mode         string
+	reportFormat string
+
+	scmProvider string
+	gitlab      gitLabOptions
+	bitbucket   bitbucketOptions
+	azureDevOps azureDevOpsOptions
+
+	concurrency int
+	failFast    bool
+
+	createPR        bool
+	prOrg           string
+	prRepo          string
+	prBaseBranch    string
+	prCommitMessage string
+	gitName         string
+	gitEmail        string
+}
+
+// needsGitHubClient reports whether the tool needs a real GitHub client this run: to fetch repo
+// metadata directly (--scm-provider=github), to read the live state of the destination org
+// (--mode=check, which always targets a GitHub org regardless of --scm-provider) or to open a
+// pull request (--create-pr).
+func (o options) needsGitHubClient() bool {
+	return o.scmProvider == scmProviderGitHub || o.mode == modeCheck || o.createPR
 }
 
 func gatherOptions() options {
@@ -45,6 +85,26 @@ func gatherOptions() options {
 	fs.StringVar(&o.peribolosConfig, "peribolos-config", "", "Peribolos configuration file")
 	fs.StringVar(&o.releaseRepoPath, "release-repo-path", "", "Path to a openshift/release repository directory")
 	fs.StringVar(&o.destOrg, "destination-org", "", "Destination name of the peribolos configuration organzation")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Do not write the peribolos configuration file, print a diff of the proposed change and exit non-zero if one exists")
+	fs.BoolVar(&o.debug, "debug", false, "Log at debug level and print the full causal chain with stacktraces for fatal errors")
+
+	fs.StringVar(&o.mode, "mode", modeGenerate, fmt.Sprintf("Operating mode: %q generates the peribolos repos block, %q reports drift between the file, the live GitHub org and the selection criteria", modeGenerate, modeCheck))
+	fs.StringVar(&o.reportFormat, "report-format", reportFormatText, fmt.Sprintf("Drift report format in %q mode: %q or %q", modeCheck, reportFormatText, reportFormatJSON))
+
+	fs.StringVar(&o.scmProvider, "scm-provider", scmProviderGitHub, fmt.Sprintf("Source code forge to fetch repository metadata from: %s, %s, %s or %s", scmProviderGitHub, scmProviderGitLab, scmProviderBitbucket, scmProviderAzureDevOps))
+	o.gitlab.Bind(fs)
+	o.bitbucket.Bind(fs)
+	o.azureDevOps.Bind(fs)
+	fs.IntVar(&o.concurrency, "concurrency", 8, "Number of concurrent GetRepo calls to make against the SCM provider")
+	fs.BoolVar(&o.failFast, "fail-fast", false, "Abort on the first repo that fails to fetch instead of collecting errors from every repo")
+
+	fs.BoolVar(&o.createPR, "create-pr", false, "When the generated configuration differs from the one on disk, open a pull request with the change and exit zero once it is opened; requires --dry-run, so the file on disk is not written")
+	fs.StringVar(&o.prOrg, "pr-org", "", "Org of the repository the peribolos config lives in, used to open the pull request")
+	fs.StringVar(&o.prRepo, "pr-repo", "", "Repository the peribolos config lives in, used to open the pull request")
+	fs.StringVar(&o.prBaseBranch, "pr-base-branch", "master", "Base branch to open the pull request against")
+	fs.StringVar(&o.prCommitMessage, "pr-commit-message", "Update peribolos configuration for %s", "Commit message template for the pull request commit, '%s' is replaced with --destination-org")
+	fs.StringVar(&o.gitName, "git-name", "", "Name to use on the git commit, required when --create-pr is set")
+	fs.StringVar(&o.gitEmail, "git-email", "", "Email to use on the git commit, required when --create-pr is set")
 
 	o.github.AddFlags(fs)
 	o.WhitelistOptions.Bind(fs)
@@ -65,8 +125,54 @@ func validateOptions(o *options) error {
 	if len(o.destOrg) == 0 {
 		validationErrors = append(validationErrors, errors.New("--destination-org is not specified"))
 	}
-	if err := o.github.Validate(false); err != nil {
-		validationErrors = append(validationErrors, err)
+	if o.concurrency < 1 {
+		validationErrors = append(validationErrors, errors.New("--concurrency must be at least 1"))
+	}
+	if o.mode != modeGenerate && o.mode != modeCheck {
+		validationErrors = append(validationErrors, fmt.Errorf("--mode must be %q or %q", modeGenerate, modeCheck))
+	}
+	if o.reportFormat != reportFormatText && o.reportFormat != reportFormatJSON {
+		validationErrors = append(validationErrors, fmt.Errorf("--report-format must be %q or %q", reportFormatText, reportFormatJSON))
+	}
+	switch o.scmProvider {
+	case scmProviderGitHub:
+		// authenticated via o.github below, nothing extra to validate.
+	case scmProviderGitLab:
+		if err := o.gitlab.Validate(); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
+	case scmProviderBitbucket:
+		if err := o.bitbucket.Validate(); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
+	case scmProviderAzureDevOps:
+		if err := o.azureDevOps.Validate(); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Errorf("--scm-provider must be one of %s, %s, %s or %s", scmProviderGitHub, scmProviderGitLab, scmProviderBitbucket, scmProviderAzureDevOps))
+	}
+	if o.createPR {
+		if !o.dryRun {
+			validationErrors = append(validationErrors, errors.New("--create-pr requires --dry-run"))
+		}
+		if len(o.prOrg) == 0 {
+			validationErrors = append(validationErrors, errors.New("--pr-org is not specified"))
+		}
+		if len(o.prRepo) == 0 {
+			validationErrors = append(validationErrors, errors.New("--pr-repo is not specified"))
+		}
+		if len(o.gitName) == 0 {
+			validationErrors = append(validationErrors, errors.New("--git-name is not specified"))
+		}
+		if len(o.gitEmail) == 0 {
+			validationErrors = append(validationErrors, errors.New("--git-email is not specified"))
+		}
+	}
+	if o.needsGitHubClient() {
+		if err := o.github.Validate(false); err != nil {
+			validationErrors = append(validationErrors, err)
+		}
 	}
 	if err := o.Validate(); err != nil {
 		validationErrors = append(validationErrors, err)
@@ -74,13 +180,28 @@ func validateOptions(o *options) error {
 	return kerrors.NewAggregate(validationErrors)
 }
 
+// fatal logs err and exits 1. With --debug it prints the full causal chain and stacktrace via
+// pkg/errors' %+v formatting; otherwise it logs only the combined error message, matching the
+// tool's default log verbosity.
+func fatal(logger *logrus.Entry, debug bool, err error) {
+	if debug {
+		logger.Errorf("%+v", err)
+	} else {
+		logger.Error(err)
+	}
+	os.Exit(1)
+}
+
 func main() {
 	o := gatherOptions()
-	err := validateOptions(&o)
-	if err != nil {
-		logrus.WithError(err).Fatal("invalid options")
-	}
 	logger := logrus.WithField("destination-org", o.destOrg)
+	if o.debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	if err := validateOptions(&o); err != nil {
+		fatal(logger, o.debug, errors.Wrap(err, "invalid options"))
+	}
 
 	go func() {
 		interrupts.WaitForGracefulShutdown()
@@ -89,72 +210,158 @@ func main() {
 
 	b, err := ioutil.ReadFile(o.peribolosConfig)
 	if err != nil {
-		logger.WithError(err).Fatal("could not read peribolos configuration file")
+		fatal(logger, o.debug, errors.Wrapf(err, "could not read peribolos configuration file %s", o.peribolosConfig))
 	}
 
 	var peribolosConfig org.FullConfig
 	if err := yaml.Unmarshal(b, &peribolosConfig); err != nil {
-		logger.WithError(err).Fatal("failed to unmarshal peribolos config")
+		fatal(logger, o.debug, errors.Wrapf(err, "failed to unmarshal peribolos config %s", o.peribolosConfig))
 	}
 
-	secretAgent := &secret.Agent{}
-	if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
-		logrus.WithError(err).Fatal("Error starting secrets agent.")
+	var gc github.Client
+	if o.needsGitHubClient() {
+		secretAgent := &secret.Agent{}
+		if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
+			fatal(logger, o.debug, errors.Wrap(err, "error starting secrets agent"))
+		}
+		gc, err = o.github.GitHubClient(secretAgent, false)
+		if err != nil {
+			fatal(logger, o.debug, errors.Wrap(err, "error getting GitHub client"))
+		}
 	}
-	gc, err := o.github.GitHubClient(secretAgent, false)
+
+	orgRepos, err := getReposForPrivateOrg(o.releaseRepoPath, o.WhitelistOptions.WhitelistConfig.Whitelist)
 	if err != nil {
-		logger.WithError(err).Fatal("Error getting GitHub client.")
+		fatal(logger, o.debug, errors.Wrapf(err, "couldn't get the list of org/repos that promote official images from %s", o.releaseRepoPath))
 	}
 
-	orgRepos, err := getReposForPrivateOrg(o.releaseRepoPath, o.WhitelistOptions.WhitelistConfig.Whitelist)
+	provider, err := newRepoMetadataProvider(o, gc)
+	if err != nil {
+		fatal(logger, o.debug, errors.Wrapf(err, "couldn't construct the %s repo metadata provider", o.scmProvider))
+	}
+
+	peribolosRepos, err := generateRepositories(provider, orgRepos, logger, o.concurrency, o.failFast)
 	if err != nil {
-		logger.WithError(err).Fatal("couldn't get the list of org/repos that promote official images")
+		fatal(logger, o.debug, errors.Wrap(err, "couldn't get repo details for one or more repos"))
+	}
+
+	if o.mode == modeCheck {
+		report, err := checkDrift(gc, o, peribolosConfig.Orgs[o.destOrg].Repos, peribolosRepos, logger)
+		if err != nil {
+			fatal(logger, o.debug, errors.Wrapf(err, "couldn't compute drift report for org %s", o.destOrg))
+		}
+		if err := printDriftReport(report, o.reportFormat); err != nil {
+			fatal(logger, o.debug, errors.Wrap(err, "couldn't print drift report"))
+		}
+		if report.hasDrift() {
+			os.Exit(1)
+		}
+		return
 	}
 
-	peribolosRepos := generateRepositories(gc, orgRepos, logger)
 	peribolosConfigByOrg := peribolosConfig.Orgs[o.destOrg]
 	peribolosConfigByOrg.Repos = peribolosRepos
 	peribolosConfig.Orgs[o.destOrg] = peribolosConfigByOrg
 
 	out, err := yaml.Marshal(peribolosConfig)
 	if err != nil {
-		logrus.WithError(err).Fatalf("%s failed to marshal output.", o.peribolosConfig)
+		fatal(logger, o.debug, errors.Wrapf(err, "failed to marshal output for %s", o.peribolosConfig))
 	}
 
-	if err := ioutil.WriteFile(o.peribolosConfig, out, 0666); err != nil {
-		logrus.WithError(err).Fatal("Failed to write output.")
+	if !o.dryRun {
+		if err := ioutil.WriteFile(o.peribolosConfig, out, 0666); err != nil {
+			fatal(logger, o.debug, errors.Wrapf(err, "failed to write output to %s", o.peribolosConfig))
+		}
+		return
 	}
+
+	diff, err := diffConfig(b, out, o.peribolosConfig)
+	if err != nil {
+		fatal(logger, o.debug, errors.Wrap(err, "failed to diff the proposed peribolos configuration"))
+	}
+	if diff == "" {
+		logger.Info("peribolos configuration is up to date, nothing to do")
+		return
+	}
+	fmt.Println(diff)
+
+	if o.createPR {
+		if err := createConfigPR(gc, o, out); err != nil {
+			fatal(logger, o.debug, errors.Wrapf(err, "failed to create a pull request against %s/%s", o.prOrg, o.prRepo))
+		}
+		return
+	}
+
+	os.Exit(1)
 }
 
-func generateRepositories(gc gitHubClient, orgRepos map[string]sets.String, logger *logrus.Entry) map[string]org.Repo {
-	peribolosRepos := make(map[string]org.Repo)
+// diffConfig returns a unified diff between the peribolos configuration currently on disk and the
+// one the tool would generate, or the empty string if they are identical.
+func diffConfig(before, after []byte, path string) (string, error) {
+	if string(before) == string(after) {
+		return "", nil
+	}
 
-	for orgName, repos := range orgRepos {
-		for repo := range repos {
-			logger.WithFields(logrus.Fields{"org": orgName, "repo": repo}).Info("Processing repository details...")
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
+	}
+	return difflib.GetUnifiedDiffString(diff)
+}
 
-			fullRepo, err := gc.GetRepo(orgName, repo)
-			if err != nil {
-				logger.WithError(err).Fatal("couldn't get repo details")
-			}
+// createConfigPR forks the configured repository, commits the updated peribolos configuration to
+// a new branch and opens a pull request against o.prBaseBranch. It mirrors the fork/branch/commit/PR
+// flow used by other peribolos-adjacent reconcilers so this tool can run unattended on a schedule.
+func createConfigPR(gc prGitHubClient, o options, content []byte) error {
+	fork, err := gc.EnsureFork(o.prOrg, o.prRepo, "")
+	if err != nil {
+		return errors.Wrapf(err, "failed to ensure a fork of %s/%s", o.prOrg, o.prRepo)
+	}
 
-			peribolosRepos[fullRepo.Name] = org.PruneRepoDefaults(org.Repo{
-				Description:      &fullRepo.Description,
-				HomePage:         &fullRepo.Homepage,
-				Private:          &fullRepo.Private,
-				HasIssues:        &fullRepo.HasIssues,
-				HasProjects:      &fullRepo.HasProjects,
-				HasWiki:          &fullRepo.HasWiki,
-				AllowMergeCommit: &fullRepo.AllowMergeCommit,
-				AllowSquashMerge: &fullRepo.AllowSquashMerge,
-				AllowRebaseMerge: &fullRepo.AllowRebaseMerge,
-				Archived:         &fullRepo.Archived,
-				DefaultBranch:    &fullRepo.DefaultBranch,
-			})
+	clientFactory, err := o.github.GitClientFactory("", nil, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to construct git client factory")
+	}
+	repoClient, err := clientFactory.ClientFor(o.prOrg, o.prRepo)
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone %s/%s", o.prOrg, o.prRepo)
+	}
+	defer func() {
+		if err := repoClient.Clean(); err != nil {
+			logrus.WithError(err).Warn("failed to clean up git client")
 		}
+	}()
+
+	if err := repoClient.Checkout(o.prBaseBranch); err != nil {
+		return errors.Wrapf(err, "failed to check out %s", o.prBaseBranch)
+	}
+
+	headBranch := fmt.Sprintf("peribolos-sync-%s", o.destOrg)
+	if err := repoClient.CheckoutNewBranch(headBranch); err != nil {
+		return errors.Wrapf(err, "failed to check out new branch %s", headBranch)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(repoClient.Directory(), o.peribolosConfig), content, 0666); err != nil {
+		return errors.Wrap(err, "failed to write peribolos configuration into the git checkout")
+	}
+
+	commitMessage := fmt.Sprintf(o.prCommitMessage, o.destOrg)
+	if err := repoClient.Commit(commitMessage, &gitv2.CommitOptions{Name: o.gitName, Email: o.gitEmail}); err != nil {
+		return errors.Wrap(err, "failed to commit updated peribolos configuration")
+	}
+
+	if err := repoClient.PushToNamedFork(fork, headBranch, true); err != nil {
+		return errors.Wrapf(err, "failed to push %s to %s", headBranch, fork)
+	}
+
+	if _, err := gc.CreatePullRequest(o.prOrg, o.prRepo, commitMessage, "Generated by private-org-peribolos-sync in --create-pr mode.", fmt.Sprintf("%s:%s", fork, headBranch), o.prBaseBranch, true); err != nil {
+		return errors.Wrap(err, "failed to create pull request")
 	}
 
-	return peribolosRepos
+	return nil
 }
 
 // getReposForPrivateOrg itterates through the release repository directory and creates a map of
@@ -186,8 +393,9 @@ func getReposForPrivateOrg(releaseRepoPath string, whitelist map[string][]string
 		return nil
 	}
 
-	if err := config.OperateOnCIOperatorConfigDir(filepath.Join(releaseRepoPath, config.CiopConfigInRepoPath), callback); err != nil {
-		return ret, fmt.Errorf("error while operating in ci-operator configuration files: %v", err)
+	ciopConfigDir := filepath.Join(releaseRepoPath, config.CiopConfigInRepoPath)
+	if err := config.OperateOnCIOperatorConfigDir(ciopConfigDir, callback); err != nil {
+		return ret, errors.Wrapf(err, "error while operating in ci-operator configuration files in %s", ciopConfigDir)
 	}
 
 	return ret, nil
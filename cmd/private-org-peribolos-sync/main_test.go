@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{
+			name:   "identical content produces no diff",
+			before: "orgs:\n  foo: {}\n",
+			after:  "orgs:\n  foo: {}\n",
+		},
+		{
+			name:   "changed content produces a unified diff",
+			before: "orgs:\n  foo: {}\n",
+			after:  "orgs:\n  foo:\n    repos: {}\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := diffConfig([]byte(tc.before), []byte(tc.after), "peribolos.yaml")
+			if err != nil {
+				t.Fatalf("diffConfig() returned error: %v", err)
+			}
+
+			wantEmpty := tc.before == tc.after
+			if gotEmpty := diff == ""; gotEmpty != wantEmpty {
+				t.Errorf("diffConfig() empty = %v, want %v (diff: %q)", gotEmpty, wantEmpty, diff)
+			}
+			if !wantEmpty && !strings.Contains(diff, "peribolos.yaml") {
+				t.Errorf("diffConfig() = %q, want it to reference the file path", diff)
+			}
+		})
+	}
+}
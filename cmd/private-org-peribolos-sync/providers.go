@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config/org"
+	"k8s.io/test-infra/prow/github"
+)
+
+// RepoMetadata is the normalized subset of repository settings this tool needs in order to
+// populate a peribolos org.Repo entry, independent of which forge it was fetched from.
+type RepoMetadata struct {
+	Description      string
+	Homepage         string
+	Private          bool
+	Archived         bool
+	DefaultBranch    string
+	HasIssues        bool
+	HasProjects      bool
+	HasWiki          bool
+	AllowMergeCommit bool
+	AllowSquashMerge bool
+	AllowRebaseMerge bool
+
+	// UnmanagedFields names RepoMetadata fields (by their RepoMetadata name, e.g. "Homepage" for
+	// org.Repo's HomePage) that this provider has no way to report because its forge has no
+	// equivalent setting. generateRepositories leaves the corresponding org.Repo field unset, so
+	// the generated config does not manage that setting, rather than setting it to a meaningless
+	// zero value.
+	UnmanagedFields sets.String
+}
+
+// RepoMetadataProvider fetches normalized repository metadata for a repo identified by its owner
+// (org/group/project) and name, regardless of which source code forge actually hosts it.
+type RepoMetadataProvider interface {
+	GetRepo(owner, name string) (RepoMetadata, error)
+}
+
+const (
+	scmProviderGitHub      = "github"
+	scmProviderGitLab      = "gitlab"
+	scmProviderBitbucket   = "bitbucket"
+	scmProviderAzureDevOps = "azuredevops"
+)
+
+// newRepoMetadataProvider constructs the RepoMetadataProvider selected via --scm-provider. The
+// GitHub provider reuses the gitHubClient the tool already authenticates for other purposes (e.g.
+// --create-pr); the others authenticate independently via their own flags.
+func newRepoMetadataProvider(o options, gc gitHubClient) (RepoMetadataProvider, error) {
+	switch o.scmProvider {
+	case scmProviderGitHub:
+		return &gitHubProvider{gc: gc}, nil
+	case scmProviderGitLab:
+		return newGitLabProvider(o.gitlab)
+	case scmProviderBitbucket:
+		return newBitbucketProvider(o.bitbucket)
+	case scmProviderAzureDevOps:
+		return newAzureDevOpsProvider(o.azureDevOps)
+	default:
+		return nil, errors.Errorf("unknown --scm-provider %q", o.scmProvider)
+	}
+}
+
+// rateLimitedError is implemented by errors that know how long the caller should wait before
+// retrying, e.g. an HTTP 429 response carrying a Retry-After header.
+type rateLimitedError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// httpRateLimitError wraps a non-GitHub provider error with the backoff duration its forge asked
+// for via a Retry-After response header.
+type httpRateLimitError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *httpRateLimitError) Error() string { return e.err.Error() }
+func (e *httpRateLimitError) Unwrap() error { return e.err }
+func (e *httpRateLimitError) RetryAfter() time.Duration {
+	return e.after
+}
+
+// parseRetryAfter extracts a Retry-After delay (seconds or HTTP date form) from a response, or
+// zero if the header is absent or unparseable.
+func parseRetryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// buildOrgRepo maps RepoMetadata onto an org.Repo, leaving any field named in UnmanagedFields
+// unset so the generated entry does not manage a setting this provider can't report, instead of
+// setting it to the field's zero value.
+func buildOrgRepo(metadata RepoMetadata) org.Repo {
+	repo := org.Repo{
+		Description:      &metadata.Description,
+		HomePage:         &metadata.Homepage,
+		Private:          &metadata.Private,
+		Archived:         &metadata.Archived,
+		DefaultBranch:    &metadata.DefaultBranch,
+		HasIssues:        &metadata.HasIssues,
+		HasProjects:      &metadata.HasProjects,
+		HasWiki:          &metadata.HasWiki,
+		AllowMergeCommit: &metadata.AllowMergeCommit,
+		AllowSquashMerge: &metadata.AllowSquashMerge,
+		AllowRebaseMerge: &metadata.AllowRebaseMerge,
+	}
+
+	if metadata.UnmanagedFields.Has("Description") {
+		repo.Description = nil
+	}
+	if metadata.UnmanagedFields.Has("Homepage") {
+		repo.HomePage = nil
+	}
+	if metadata.UnmanagedFields.Has("Private") {
+		repo.Private = nil
+	}
+	if metadata.UnmanagedFields.Has("Archived") {
+		repo.Archived = nil
+	}
+	if metadata.UnmanagedFields.Has("DefaultBranch") {
+		repo.DefaultBranch = nil
+	}
+	if metadata.UnmanagedFields.Has("HasIssues") {
+		repo.HasIssues = nil
+	}
+	if metadata.UnmanagedFields.Has("HasProjects") {
+		repo.HasProjects = nil
+	}
+	if metadata.UnmanagedFields.Has("HasWiki") {
+		repo.HasWiki = nil
+	}
+	if metadata.UnmanagedFields.Has("AllowMergeCommit") {
+		repo.AllowMergeCommit = nil
+	}
+	if metadata.UnmanagedFields.Has("AllowSquashMerge") {
+		repo.AllowSquashMerge = nil
+	}
+	if metadata.UnmanagedFields.Has("AllowRebaseMerge") {
+		repo.AllowRebaseMerge = nil
+	}
+
+	return org.PruneRepoDefaults(repo)
+}
+
+// gitHubProvider adapts the existing gitHubClient to RepoMetadataProvider.
+type gitHubProvider struct {
+	gc gitHubClient
+}
+
+func (p *gitHubProvider) GetRepo(owner, name string) (RepoMetadata, error) {
+	fullRepo, err := p.gc.GetRepo(owner, name)
+	if err != nil {
+		return RepoMetadata{}, wrapGitHubRateLimit(err)
+	}
+
+	return RepoMetadata{
+		Description:      fullRepo.Description,
+		Homepage:         fullRepo.Homepage,
+		Private:          fullRepo.Private,
+		Archived:         fullRepo.Archived,
+		DefaultBranch:    fullRepo.DefaultBranch,
+		HasIssues:        fullRepo.HasIssues,
+		HasProjects:      fullRepo.HasProjects,
+		HasWiki:          fullRepo.HasWiki,
+		AllowMergeCommit: fullRepo.AllowMergeCommit,
+		AllowSquashMerge: fullRepo.AllowSquashMerge,
+		AllowRebaseMerge: fullRepo.AllowRebaseMerge,
+	}, nil
+}
+
+// wrapGitHubRateLimit recognizes a primary GitHub rate limit error (an HTTP 403 carrying an
+// X-RateLimit-Reset header, surfaced by the client as *github.RateLimitError) and wraps it in a
+// rateLimitedError so retryBackoff can honor the reset time instead of guessing with exponential
+// backoff, the same way it already does for the secondary/abuse limit and for the other providers'
+// HTTP 429 responses.
+func wrapGitHubRateLimit(err error) error {
+	var rlErr *github.RateLimitError
+	if errors.As(err, &rlErr) {
+		return &httpRateLimitError{err: err, after: time.Until(rlErr.Rate.Reset.Time)}
+	}
+	return err
+}
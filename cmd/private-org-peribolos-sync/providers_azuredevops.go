@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type azureDevOpsOptions struct {
+	tokenPath    string
+	organization string
+	project      string
+}
+
+func (o *azureDevOpsOptions) Bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.tokenPath, "azuredevops-token-path", "", "Path to a file containing an Azure DevOps personal access token, required when --scm-provider=azuredevops")
+	fs.StringVar(&o.organization, "azuredevops-organization", "", "Azure DevOps organization, required when --scm-provider=azuredevops")
+	fs.StringVar(&o.project, "azuredevops-project", "", "Azure DevOps project, required when --scm-provider=azuredevops")
+}
+
+func (o *azureDevOpsOptions) Validate() error {
+	var validationErrors []string
+	if len(o.tokenPath) == 0 {
+		validationErrors = append(validationErrors, "--azuredevops-token-path is not specified")
+	}
+	if len(o.organization) == 0 {
+		validationErrors = append(validationErrors, "--azuredevops-organization is not specified")
+	}
+	if len(o.project) == 0 {
+		validationErrors = append(validationErrors, "--azuredevops-project is not specified")
+	}
+	if len(validationErrors) > 0 {
+		return errors.New(strings.Join(validationErrors, ", "))
+	}
+	return nil
+}
+
+// azureDevOpsProvider fetches repository metadata from the Azure DevOps Git REST API. The `owner`
+// passed to GetRepo is ignored: Azure Repos are scoped to an organization/project configured on
+// the provider rather than addressed per-call, and it is kept only so GetRepo satisfies
+// RepoMetadataProvider. Azure Repos has no repository-level merge-strategy settings (those live on
+// branch policies) and no repo-level description or issues/Projects/wiki toggle (Azure Boards and
+// Azure Wikis are enabled per-project, not per-repo), so all of those are reported via
+// UnmanagedFields instead of a zero value that peribolos would otherwise treat as "explicitly
+// disabled".
+type azureDevOpsProvider struct {
+	client       *http.Client
+	organization string
+	project      string
+	authHeader   string
+}
+
+func newAzureDevOpsProvider(o azureDevOpsOptions) (*azureDevOpsProvider, error) {
+	token, err := ioutil.ReadFile(o.tokenPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read Azure DevOps token")
+	}
+
+	basicAuth := base64.StdEncoding.EncodeToString([]byte(":" + strings.TrimSpace(string(token))))
+	return &azureDevOpsProvider{
+		client:       &http.Client{},
+		organization: o.organization,
+		project:      o.project,
+		authHeader:   "Basic " + basicAuth,
+	}, nil
+}
+
+type azureDevOpsRepository struct {
+	DefaultBranch string `json:"defaultBranch"`
+	IsDisabled    bool   `json:"isDisabled"`
+	WebURL        string `json:"webUrl"`
+}
+
+func (p *azureDevOpsProvider) GetRepo(_, name string) (RepoMetadata, error) {
+	u := fmt.Sprintf("https://dev.azure.com/%s/%s/_apis/git/repositories/%s?api-version=6.0", p.organization, p.project, name)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return RepoMetadata{}, errors.Wrapf(err, "failed to construct request for %s", name)
+	}
+	req.Header.Set("Authorization", p.authHeader)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return RepoMetadata{}, errors.Wrapf(err, "failed to fetch repository %s", name)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return RepoMetadata{}, &httpRateLimitError{
+			err:   errors.Errorf("rate limited fetching repository %s", name),
+			after: parseRetryAfter(resp),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return RepoMetadata{}, errors.Errorf("failed to fetch repository %s: status %s", name, resp.Status)
+	}
+
+	var repo azureDevOpsRepository
+	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+		return RepoMetadata{}, errors.Wrapf(err, "failed to decode repository %s", name)
+	}
+
+	return RepoMetadata{
+		Private:       true,
+		Archived:      repo.IsDisabled,
+		DefaultBranch: strings.TrimPrefix(repo.DefaultBranch, "refs/heads/"),
+		Homepage:      repo.WebURL,
+		UnmanagedFields: sets.NewString(
+			"Description", "HasIssues", "HasProjects", "HasWiki",
+			"AllowMergeCommit", "AllowSquashMerge", "AllowRebaseMerge",
+		),
+	}, nil
+}
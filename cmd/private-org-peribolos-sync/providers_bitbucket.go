@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type bitbucketOptions struct {
+	tokenPath string
+	baseURL   string
+}
+
+func (o *bitbucketOptions) Bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.tokenPath, "bitbucket-token-path", "", "Path to a file containing a Bitbucket Server access token, required when --scm-provider=bitbucket")
+	fs.StringVar(&o.baseURL, "bitbucket-base-url", "", "Base URL of the Bitbucket Server instance, required when --scm-provider=bitbucket")
+}
+
+func (o *bitbucketOptions) Validate() error {
+	var validationErrors []string
+	if len(o.tokenPath) == 0 {
+		validationErrors = append(validationErrors, "--bitbucket-token-path is not specified")
+	}
+	if len(o.baseURL) == 0 {
+		validationErrors = append(validationErrors, "--bitbucket-base-url is not specified")
+	}
+	if len(validationErrors) > 0 {
+		return errors.New(strings.Join(validationErrors, ", "))
+	}
+	return nil
+}
+
+// bitbucketProvider fetches repository metadata from the Bitbucket Server (Data Center) REST API.
+// Bitbucket Server has no notion of a repository "homepage", so the repository's own web link
+// (links.self) is reported in its place. It does not expose merge-strategy restrictions on the
+// repository resource itself, so those are derived from the repo's pull request settings endpoint.
+type bitbucketProvider struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+func newBitbucketProvider(o bitbucketOptions) (*bitbucketProvider, error) {
+	token, err := ioutil.ReadFile(o.tokenPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read Bitbucket token")
+	}
+
+	return &bitbucketProvider{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(o.baseURL, "/"),
+		token:   strings.TrimSpace(string(token)),
+	}, nil
+}
+
+type bitbucketRepository struct {
+	Description   string `json:"description"`
+	Public        bool   `json:"public"`
+	State         string `json:"state"`
+	DefaultBranch string `json:"defaultBranch"`
+	Links         struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+type bitbucketPullRequestSettings struct {
+	MergeConfig struct {
+		Types []struct {
+			ID      string `json:"id"`
+			Enabled bool   `json:"enabled"`
+		} `json:"types"`
+	} `json:"mergeConfig"`
+}
+
+func (p *bitbucketProvider) get(path string, into interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to construct request for %s", path)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &httpRateLimitError{
+			err:   errors.Errorf("rate limited fetching %s", path),
+			after: parseRetryAfter(resp),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to fetch %s: status %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+func (p *bitbucketProvider) GetRepo(owner, name string) (RepoMetadata, error) {
+	var repo bitbucketRepository
+	if err := p.get(fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", owner, name), &repo); err != nil {
+		return RepoMetadata{}, err
+	}
+
+	var prSettings bitbucketPullRequestSettings
+	if err := p.get(fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/settings/pull-requests", owner, name), &prSettings); err != nil {
+		return RepoMetadata{}, err
+	}
+	enabled := map[string]bool{}
+	for _, t := range prSettings.MergeConfig.Types {
+		enabled[t.ID] = t.Enabled
+	}
+
+	var homepage string
+	if len(repo.Links.Self) > 0 {
+		homepage = repo.Links.Self[0].Href
+	}
+
+	return RepoMetadata{
+		Description:      repo.Description,
+		Homepage:         homepage,
+		Private:          !repo.Public,
+		Archived:         repo.State == "ARCHIVED",
+		DefaultBranch:    repo.DefaultBranch,
+		AllowMergeCommit: enabled["no-ff"] || enabled["merge-commit"],
+		AllowSquashMerge: enabled["squash"],
+		AllowRebaseMerge: enabled["rebase-no-ff"] || enabled["rebase"],
+		// Bitbucket Server has no per-repo issue tracker, Projects feature or wiki toggle
+		// exposed via this API.
+		UnmanagedFields: sets.NewString("HasIssues", "HasProjects", "HasWiki"),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type gitLabOptions struct {
+	tokenPath string
+	baseURL   string
+}
+
+func (o *gitLabOptions) Bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.tokenPath, "gitlab-token-path", "", "Path to a file containing a GitLab personal access token, required when --scm-provider=gitlab")
+	fs.StringVar(&o.baseURL, "gitlab-base-url", "https://gitlab.com", "Base URL of the GitLab instance")
+}
+
+func (o *gitLabOptions) Validate() error {
+	if len(o.tokenPath) == 0 {
+		return errors.New("--gitlab-token-path is not specified")
+	}
+	return nil
+}
+
+// gitLabProvider fetches repository metadata from the GitLab REST API.
+type gitLabProvider struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+func newGitLabProvider(o gitLabOptions) (*gitLabProvider, error) {
+	token, err := ioutil.ReadFile(o.tokenPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read GitLab token")
+	}
+
+	return &gitLabProvider{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(o.baseURL, "/"),
+		token:   strings.TrimSpace(string(token)),
+	}, nil
+}
+
+type gitLabProject struct {
+	Description   string `json:"description"`
+	WebURL        string `json:"web_url"`
+	Visibility    string `json:"visibility"`
+	Archived      bool   `json:"archived"`
+	DefaultBranch string `json:"default_branch"`
+	MergeMethod   string `json:"merge_method"`
+	SquashOption  string `json:"squash_option"`
+	IssuesEnabled bool   `json:"issues_enabled"`
+	WikiEnabled   bool   `json:"wiki_enabled"`
+}
+
+func (p *gitLabProvider) GetRepo(owner, name string) (RepoMetadata, error) {
+	projectPath := url.QueryEscape(fmt.Sprintf("%s/%s", owner, name))
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v4/projects/%s", p.baseURL, projectPath), nil)
+	if err != nil {
+		return RepoMetadata{}, errors.Wrapf(err, "failed to construct request for %s/%s", owner, name)
+	}
+	req.Header.Set("PRIVATE-TOKEN", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return RepoMetadata{}, errors.Wrapf(err, "failed to fetch project %s/%s", owner, name)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return RepoMetadata{}, &httpRateLimitError{
+			err:   errors.Errorf("rate limited fetching project %s/%s", owner, name),
+			after: parseRetryAfter(resp),
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return RepoMetadata{}, errors.Errorf("failed to fetch project %s/%s: status %s", owner, name, resp.Status)
+	}
+
+	var project gitLabProject
+	if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
+		return RepoMetadata{}, errors.Wrapf(err, "failed to decode project %s/%s", owner, name)
+	}
+
+	return RepoMetadata{
+		Description:      project.Description,
+		Homepage:         project.WebURL,
+		Private:          project.Visibility != "public",
+		Archived:         project.Archived,
+		DefaultBranch:    project.DefaultBranch,
+		HasIssues:        project.IssuesEnabled,
+		HasWiki:          project.WikiEnabled,
+		AllowMergeCommit: project.MergeMethod == "merge",
+		AllowRebaseMerge: project.MergeMethod == "rebase_merge",
+		AllowSquashMerge: project.SquashOption != "never",
+		// GitLab has no equivalent to GitHub's per-repo Projects toggle.
+		UnmanagedFields: sets.NewString("HasProjects"),
+	}, nil
+}
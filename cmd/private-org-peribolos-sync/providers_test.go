@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestBuildOrgRepoPrunesUnmanagedFields(t *testing.T) {
+	metadata := RepoMetadata{
+		Description:      "a repo",
+		Homepage:         "https://example.com",
+		HasIssues:        true,
+		HasProjects:      true,
+		HasWiki:          true,
+		AllowMergeCommit: false,
+		AllowSquashMerge: false,
+		AllowRebaseMerge: false,
+		UnmanagedFields: sets.NewString(
+			"Description", "Homepage", "HasIssues", "HasProjects", "HasWiki",
+			"AllowMergeCommit", "AllowSquashMerge", "AllowRebaseMerge",
+		),
+	}
+
+	repo := buildOrgRepo(metadata)
+
+	if repo.Description != nil {
+		t.Errorf("Description = %v, want nil (provider can't report it)", *repo.Description)
+	}
+	if repo.HomePage != nil {
+		t.Errorf("HomePage = %v, want nil (provider can't report it)", *repo.HomePage)
+	}
+	if repo.HasIssues != nil {
+		t.Errorf("HasIssues = %v, want nil (provider can't report it)", *repo.HasIssues)
+	}
+	if repo.HasProjects != nil {
+		t.Errorf("HasProjects = %v, want nil (provider can't report it)", *repo.HasProjects)
+	}
+	if repo.HasWiki != nil {
+		t.Errorf("HasWiki = %v, want nil (provider can't report it)", *repo.HasWiki)
+	}
+	if repo.AllowMergeCommit != nil {
+		t.Errorf("AllowMergeCommit = %v, want nil (provider can't report it)", *repo.AllowMergeCommit)
+	}
+	if repo.AllowSquashMerge != nil {
+		t.Errorf("AllowSquashMerge = %v, want nil (provider can't report it)", *repo.AllowSquashMerge)
+	}
+	if repo.AllowRebaseMerge != nil {
+		t.Errorf("AllowRebaseMerge = %v, want nil (provider can't report it)", *repo.AllowRebaseMerge)
+	}
+}
+
+func TestBuildOrgRepoKeepsManagedFields(t *testing.T) {
+	metadata := RepoMetadata{
+		Description:     "a repo",
+		Homepage:        "https://example.com",
+		UnmanagedFields: sets.NewString("HasIssues", "HasProjects", "HasWiki"),
+	}
+
+	repo := buildOrgRepo(metadata)
+
+	if repo.Description == nil || *repo.Description != metadata.Description {
+		t.Errorf("Description = %v, want %q", repo.Description, metadata.Description)
+	}
+	if repo.HomePage == nil || *repo.HomePage != metadata.Homepage {
+		t.Errorf("HomePage = %v, want %q", repo.HomePage, metadata.Homepage)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{
+			name:   "no header",
+			header: "",
+			want:   0,
+		},
+		{
+			name:   "seconds form",
+			header: "120",
+			want:   120 * time.Second,
+		},
+		{
+			name:   "unparseable value",
+			header: "not-a-duration",
+			want:   0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.header != "" {
+				resp.Header.Set("Retry-After", tc.header)
+			}
+
+			if got := parseRetryAfter(resp); got != tc.want {
+				t.Errorf("parseRetryAfter() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}